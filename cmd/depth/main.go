@@ -0,0 +1,109 @@
+// Command depth prints the dependency tree of a Go package.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charlievieth/depth"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] [import-path]\n\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+// ignoreFlags collects repeated -ignore flag values.
+type ignoreFlags []string
+
+func (i *ignoreFlags) String() string {
+	return strings.Join(*i, ",")
+}
+
+func (i *ignoreFlags) Set(pattern string) error {
+	*i = append(*i, pattern)
+	return nil
+}
+
+func main() {
+	modules := flag.Bool("modules", false, "force module-aware resolution via golang.org/x/tools/go/packages; auto-detected from go.mod when unset")
+	test := flag.Bool("test", false, "also resolve test and external (black-box) test imports")
+	var ignore ignoreFlags
+	flag.Var(&ignore, "ignore", "package path or path/... prefix to exclude from expansion (repeatable)")
+	flag.Usage = usage
+	flag.Parse()
+
+	importPath := "."
+	if flag.NArg() > 0 {
+		importPath = flag.Arg(0)
+	}
+
+	if err := run(importPath, *modules, *test, ignore); err != nil {
+		fmt.Fprintln(os.Stderr, "depth:", err)
+		os.Exit(1)
+	}
+}
+
+func run(importPath string, modules, test bool, ignore []string) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	var importer depth.Importer
+	if modules {
+		importer = &depth.PackagesImporter{Dir: wd}
+	} else {
+		importer = depth.NewImporter(wd)
+	}
+
+	t := depth.Tree{Ignored: depth.NewIgnoredRuleset(ignore), ResolveTest: test}
+	if err := t.Resolve(importPath, importer); err != nil {
+		return err
+	}
+
+	printTree(t.Root, 0)
+
+	if errs := checkErrors(t.Root); len(errs) > 0 {
+		for _, p := range errs {
+			fmt.Fprintf(os.Stderr, "depth: failed to resolve %q\n", p.Name)
+		}
+		return fmt.Errorf("%d package(s) failed to resolve", len(errs))
+	}
+	return nil
+}
+
+// printTree writes p and its Deps to stdout as an indented tree. Deps
+// reached via a package's own "_test.go" files or an external "_test"
+// package are annotated with a "[test]"/"[xtest]" suffix so it's clear
+// which dependencies are test-only.
+func printTree(p *depth.Pkg, level int) {
+	line := strings.Repeat("  ", level) + p.String()
+	if suffix := p.TestType.String(); suffix != "" {
+		line += " [" + suffix + "]"
+	}
+	fmt.Println(line)
+	for i := range p.Deps {
+		printTree(&p.Deps[i], level+1)
+	}
+}
+
+// checkErrors walks the tree rooted at p, collecting every Pkg that failed
+// to resolve. Ignored packages (and anything beneath them, though Resolve
+// never expands their Deps) are skipped: they are never imported, so there
+// is no resolution failure to report for them.
+func checkErrors(p *depth.Pkg) []*depth.Pkg {
+	if p.Ignored {
+		return nil
+	}
+	var errs []*depth.Pkg
+	if !p.Resolved {
+		errs = append(errs, p)
+	}
+	for i := range p.Deps {
+		errs = append(errs, checkErrors(&p.Deps[i])...)
+	}
+	return errs
+}
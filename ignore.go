@@ -0,0 +1,42 @@
+package depth
+
+import "strings"
+
+// IgnoredRuleset holds a set of package paths and path/... prefix globs that
+// should be skipped during resolution. A matching package is still added to
+// the tree as a leaf (see Pkg.Ignored) but its own imports are not expanded.
+type IgnoredRuleset struct {
+	exact    map[string]struct{}
+	prefixes []string
+}
+
+// NewIgnoredRuleset builds an IgnoredRuleset from patterns. A pattern ending
+// in "/..." matches the named path and everything beneath it; any other
+// pattern matches only that exact package path.
+func NewIgnoredRuleset(patterns []string) *IgnoredRuleset {
+	r := &IgnoredRuleset{exact: make(map[string]struct{})}
+	for _, pat := range patterns {
+		if strings.HasSuffix(pat, "/...") {
+			r.prefixes = append(r.prefixes, strings.TrimSuffix(pat, "/..."))
+		} else {
+			r.exact[pat] = struct{}{}
+		}
+	}
+	return r
+}
+
+// Match reports whether name is covered by the ruleset.
+func (r *IgnoredRuleset) Match(name string) bool {
+	if r == nil {
+		return false
+	}
+	if _, ok := r.exact[name]; ok {
+		return true
+	}
+	for _, prefix := range r.prefixes {
+		if name == prefix || strings.HasPrefix(name, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package depth
+
+import "testing"
+
+func TestIgnoredRulesetMatch(t *testing.T) {
+	r := NewIgnoredRuleset([]string{"example.com/exact", "example.com/internal/..."})
+
+	cases := []struct {
+		name string
+		want bool
+	}{
+		{"example.com/exact", true},
+		{"example.com/exact/sub", false},
+		{"example.com/internal", true},
+		{"example.com/internal/sub", true},
+		{"example.com/other", false},
+	}
+	for _, c := range cases {
+		if got := r.Match(c.name); got != c.want {
+			t.Errorf("Match(%q) = %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	var nilRuleset *IgnoredRuleset
+	if nilRuleset.Match("anything") {
+		t.Error("nil *IgnoredRuleset.Match() = true, want false")
+	}
+}
+
+// TestTreeResolveSkipsIgnored verifies that a Tree with Ignored set stops at
+// a matching package: it's kept as a leaf with Ignored set, and its own
+// imports are never expanded.
+func TestTreeResolveSkipsIgnored(t *testing.T) {
+	imp := &graphImporter{
+		imports: map[string][]string{
+			"root": {"a", "example.com/internal/gen"},
+			"a":    {"b"},
+		},
+	}
+
+	tree := Tree{Ignored: NewIgnoredRuleset([]string{"example.com/internal/..."})}
+	if err := tree.Resolve("root", imp); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	var ignored, plain *Pkg
+	for i := range tree.Root.Deps {
+		dep := &tree.Root.Deps[i]
+		switch dep.Name {
+		case "example.com/internal/gen":
+			ignored = dep
+		case "a":
+			plain = dep
+		}
+	}
+
+	if ignored == nil {
+		t.Fatal("ignored dep not found")
+	}
+	if !ignored.Ignored {
+		t.Error("ignored.Ignored = false, want true")
+	}
+	if len(ignored.Deps) != 0 {
+		t.Errorf("len(ignored.Deps) = %d, want 0 (ignored packages are not expanded)", len(ignored.Deps))
+	}
+
+	if plain == nil {
+		t.Fatal("non-ignored dep not found")
+	}
+	if plain.Ignored {
+		t.Error("plain.Ignored = true, want false")
+	}
+	if len(plain.Deps) != 1 {
+		t.Errorf("len(plain.Deps) = %d, want 1", len(plain.Deps))
+	}
+}
@@ -0,0 +1,156 @@
+package depth
+
+import (
+	"fmt"
+	"go/build"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// PackagesImporter resolves packages using golang.org/x/tools/go/packages
+// instead of go/build. Unlike the default resolution, it is module-aware:
+// it honors per-file build tags, go.work overlays, and vendored
+// replace/exclude directives recorded in go.mod.
+type PackagesImporter struct {
+	// Dir is the directory the underlying go command is invoked from. If
+	// empty, srcDir (as passed to Import) is used, falling back to the
+	// current working directory.
+	Dir string
+}
+
+// Import implements the Importer interface. When mode is build.FindOnly, the
+// load is restricted to name and file data (no Imports, no test variants),
+// matching the cheap probe that Pkg.Resolve uses to learn a package's
+// canonical path without paying for a full import.
+func (imp *PackagesImporter) Import(path, srcDir string, mode build.ImportMode) (*build.Package, error) {
+	dir := imp.Dir
+	if dir == "" {
+		dir = srcDir
+	}
+	findOnly := mode&build.FindOnly != 0
+
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles,
+		Dir:  dir,
+	}
+	if !findOnly {
+		cfg.Mode |= packages.NeedImports
+		cfg.Tests = true
+	}
+
+	pkgs, err := packages.Load(cfg, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("depth: no package found for %q", path)
+	}
+
+	pkg := findLoadedPackage(pkgs, path)
+	if pkg == nil {
+		pkg = pkgs[0]
+	}
+	if len(pkg.Errors) > 0 {
+		return nil, pkg.Errors[0]
+	}
+	return toBuildPackage(pkgs, pkg), nil
+}
+
+// findLoadedPackage returns the member of pkgs that is the plain (non-test)
+// package at path, as opposed to one of the synthetic variants Config.Tests
+// also returns: "path [path.test]" (path compiled for its own test), "path_test"
+// (the external test package) and "path.test" (the test binary).
+func findLoadedPackage(pkgs []*packages.Package, path string) *packages.Package {
+	for _, pkg := range pkgs {
+		if pkg.PkgPath == path && pkg.ID == path {
+			return pkg
+		}
+	}
+	return nil
+}
+
+// toBuildPackage converts pkg, plus its internal and external test variants
+// found among the rest of pkgs (when Config.Tests was set), into the subset
+// of *build.Package fields that Pkg.Resolve relies on.
+func toBuildPackage(pkgs []*packages.Package, pkg *packages.Package) *build.Package {
+	bp := &build.Package{
+		Name:       pkg.Name,
+		ImportPath: pkg.PkgPath,
+		Goroot:     inGoroot(pkg),
+	}
+	if len(pkg.GoFiles) > 0 {
+		bp.Dir = filepath.Dir(pkg.GoFiles[0])
+	}
+	bp.Imports = importPaths(pkg.Imports)
+
+	internalTestID := pkg.PkgPath + " [" + pkg.PkgPath + ".test]"
+	externalTestPath := pkg.PkgPath + "_test"
+	for _, other := range pkgs {
+		switch {
+		case other.ID == internalTestID:
+			// The "[x.test]" variant is pkg recompiled with its own
+			// "_test.go" files folded in, so its Imports is pkg's Imports
+			// plus whatever those test files add. TestImports is only the
+			// addition.
+			bp.TestImports = importPaths(subtractImports(other.Imports, pkg.Imports))
+		case other.PkgPath == externalTestPath:
+			bp.XTestImports = importPaths(other.Imports)
+		}
+	}
+	return bp
+}
+
+// importPaths returns the sorted import paths of imports.
+func importPaths(imports map[string]*packages.Package) []string {
+	paths := make([]string, 0, len(imports))
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// subtractImports returns the entries of a whose keys are not also in b.
+func subtractImports(a, b map[string]*packages.Package) map[string]*packages.Package {
+	diff := make(map[string]*packages.Package, len(a))
+	for path, pkg := range a {
+		if _, ok := b[path]; !ok {
+			diff[path] = pkg
+		}
+	}
+	return diff
+}
+
+// inGoroot reports whether pkg's source files live under GOROOT, which is
+// the only reliable signal for stdlib membership: a module path need not
+// contain a dot (eg. a go.mod declaring "module depth").
+func inGoroot(pkg *packages.Package) bool {
+	goroot := runtime.GOROOT()
+	if goroot == "" {
+		return false
+	}
+	prefix := filepath.Join(goroot, "src") + string(filepath.Separator)
+	files := pkg.GoFiles
+	if len(files) == 0 {
+		files = pkg.CompiledGoFiles
+	}
+	for _, f := range files {
+		return strings.HasPrefix(f, prefix)
+	}
+	return false
+}
+
+// NewImporter returns a PackagesImporter rooted at dir when dir (or one of
+// its parents) contains a go.mod file, and the default go/build-based
+// Importer otherwise. This backs the CLI's module auto-detection when the
+// -modules flag is not explicitly set.
+func NewImporter(dir string) Importer {
+	if findModuleDir(dir) != "" {
+		return &PackagesImporter{Dir: dir}
+	}
+	return &build.Default
+}
@@ -0,0 +1,77 @@
+package depth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// moduleInfo is the subset of a go.mod relevant to ranking a Pkg's
+// Relevance: the module's own path, and the paths of its required modules
+// split into direct and indirect.
+type moduleInfo struct {
+	path     string
+	direct   []string
+	indirect []string
+}
+
+// findModuleDir walks up from dir looking for a directory containing a
+// go.mod, returning "" if none is found (including when dir is empty).
+func findModuleDir(dir string) string {
+	for dir != "" {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return ""
+}
+
+// loadModuleInfo reads and parses the go.mod in dir. It returns nil if dir
+// is empty or no go.mod could be read or parsed.
+func loadModuleInfo(dir string) *moduleInfo {
+	if dir == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil
+	}
+	f, err := modfile.Parse("go.mod", data, nil)
+	if err != nil || f.Module == nil {
+		return nil
+	}
+
+	info := &moduleInfo{path: f.Module.Mod.Path}
+	for _, r := range f.Require {
+		if r.Indirect {
+			info.indirect = append(info.indirect, r.Mod.Path)
+		} else {
+			info.direct = append(info.direct, r.Mod.Path)
+		}
+	}
+	return info
+}
+
+// sameModule reports whether packagePath belongs to this module.
+func (m *moduleInfo) sameModule(packagePath string) bool {
+	return packagePath == m.path || strings.HasPrefix(packagePath, m.path+"/")
+}
+
+// matches reports whether packagePath is provided by one of the given
+// module paths.
+func (m *moduleInfo) matches(modulePaths []string, packagePath string) bool {
+	for _, modPath := range modulePaths {
+		if packagePath == modPath || strings.HasPrefix(packagePath, modPath+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package depth
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+const testGoMod = `module example.com/root
+
+go 1.21
+
+require example.com/direct v1.0.0
+
+require example.com/indirect v1.0.0 // indirect
+`
+
+// relevanceImporter resolves a fixed set of packages spanning every
+// Relevance tier: stdlib, same module, direct and indirect requires, and an
+// unrelated module absent from go.mod.
+type relevanceImporter struct{}
+
+func (relevanceImporter) Import(path, srcDir string, mode build.ImportMode) (*build.Package, error) {
+	bp := &build.Package{ImportPath: path, Dir: path}
+	if path == "fmt" {
+		bp.Goroot = true
+	}
+	return bp, nil
+}
+
+func TestTreeRelevanceOf(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(testGoMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tree := Tree{ModuleDir: dir}
+	imp := relevanceImporter{}
+
+	cases := []struct {
+		name string
+		want Relevance
+	}{
+		{"fmt", RelevanceStdlib},
+		{"example.com/root/sub", RelevanceSameModule},
+		{"example.com/direct", RelevanceDirect},
+		{"example.com/direct/sub", RelevanceDirect},
+		{"example.com/indirect", RelevanceIndirect},
+		{"example.com/unrelated", RelevanceUnknown},
+	}
+	for _, c := range cases {
+		pkg, err := imp.Import(c.name, "", 0)
+		if err != nil {
+			t.Fatalf("Import(%q) error = %v", c.name, err)
+		}
+		p := &Pkg{Name: c.name, PackagePath: pkg.ImportPath, Internal: pkg.Goroot, Tree: &tree}
+		if got := tree.relevanceOf(p); got != c.want {
+			t.Errorf("relevanceOf(%q) = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// TestByRelevanceAndNameOrder verifies that deps sort by Relevance first,
+// then by the last path component, and that RelevanceUnset (leaves that
+// never finished resolving) sorts after every known tier.
+func TestByRelevanceAndNameOrder(t *testing.T) {
+	deps := []Pkg{
+		{Name: "zzz-unresolved", Relevance: RelevanceUnset},
+		{Name: "example.com/b", Relevance: RelevanceDirect},
+		{Name: "fmt", Relevance: RelevanceStdlib},
+		{Name: "example.com/a", Relevance: RelevanceDirect},
+	}
+	sort.Sort(byRelevanceAndName(deps))
+
+	want := []string{"fmt", "example.com/a", "example.com/b", "zzz-unresolved"}
+	for i, name := range want {
+		if deps[i].Name != name {
+			t.Errorf("deps[%d].Name = %q, want %q", i, deps[i].Name, name)
+		}
+	}
+}
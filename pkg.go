@@ -1,6 +1,7 @@
 package depth
 
 import (
+	"encoding/json"
 	"go/build"
 	"path"
 	"sort"
@@ -8,20 +9,97 @@ import (
 	"sync"
 )
 
+// TestType classifies how a Pkg came to be a dependency via test imports.
+type TestType int
+
+const (
+	// TestNone means the Pkg is a regular, non-test dependency.
+	TestNone TestType = iota
+	// TestInternal means the Pkg was reached via TestImports (the package's
+	// own "_test.go" files).
+	TestInternal
+	// TestExternal means the Pkg was reached via XTestImports (an external
+	// "_test" package).
+	TestExternal
+)
+
+// String returns the lower-case name of the TestType, as used in the CLI's
+// tree output suffixes.
+func (t TestType) String() string {
+	switch t {
+	case TestInternal:
+		return "test"
+	case TestExternal:
+		return "xtest"
+	default:
+		return ""
+	}
+}
+
+// Relevance ranks a Pkg by how closely related it is to the root package of
+// its Tree, for use when ordering Deps.
+type Relevance int
+
+const (
+	// RelevanceStdlib is a package from GOROOT.
+	RelevanceStdlib Relevance = iota
+	// RelevanceSameModule is a package in the same module as the root.
+	RelevanceSameModule
+	// RelevanceDirect is a package from a module directly required by the
+	// root's go.mod.
+	RelevanceDirect
+	// RelevanceIndirect is a package from a module required only
+	// indirectly.
+	RelevanceIndirect
+	// RelevanceUnknown is anything that couldn't be related to the root's
+	// go.mod, eg. because the Tree has no ModuleDir configured.
+	RelevanceUnknown
+
+	// RelevanceUnset is the zero value's complement: it is assigned
+	// explicitly at the start of Resolve and only ever overwritten once
+	// resolution actually succeeds. Pkgs that return early (Ignored,
+	// unresolved, or the empty "C" pseudo-package) keep it, so they sort
+	// after every known tier instead of masquerading as RelevanceStdlib.
+	RelevanceUnset
+)
+
 // Pkg represents a Go source package, and its dependencies.
 type Pkg struct {
 	mu     sync.Mutex
 	Name   string `json:"name"`
 	SrcDir string `json:"-"`
 
-	Internal bool `json:"internal"`
-	Resolved bool `json:"resolved"`
-	Test     bool `json:"-"`
+	// ImportPath is the string passed to Importer.Import: the path as it
+	// appears in the importing file's import statement, except that
+	// golang_org/* paths get a "vendor/" prefix (see cleanName).
+	ImportPath string `json:"importPath"`
+
+	// PackagePath is the canonical path of the resolved package, as reported
+	// by build.Package.ImportPath. It differs from ImportPath for packages
+	// resolved through a vendor directory, eg. ImportPath
+	// "example.com/foo" resolving to PackagePath "vendor/example.com/foo".
+	PackagePath string `json:"packagePath"`
+
+	Internal bool     `json:"internal"`
+	Resolved bool     `json:"resolved"`
+	Ignored  bool     `json:"ignored"`
+	TestType TestType `json:"-"`
+
+	// Relevance ranks how closely related this Pkg is to the root of the
+	// Tree, from RelevanceStdlib (most relevant) to RelevanceUnknown (least);
+	// Pkgs that never finish resolving keep RelevanceUnset. It is populated
+	// during Resolve and used by byRelevanceAndName.
+	Relevance Relevance `json:"relevance"`
 
 	Tree   *Tree `json:"-"`
 	Parent *Pkg  `json:"-"`
 	Deps   []Pkg `json:"deps"`
 
+	// Imports maps each import path as written in this package's source to
+	// the PackagePath it resolved to, so a dependency can be looked up by
+	// either name. See DirectDep.
+	Imports map[string]string `json:"-"`
+
 	Raw *build.Package `json:"-"`
 }
 
@@ -65,50 +143,81 @@ func (p *Pkg) Resolve(i Importer) {
 	// Resolved is always true, regardless of if we skip the import,
 	// it is only false if there is an error while importing.
 	p.Resolved = true
+	p.Relevance = RelevanceUnset
 
 	name := p.cleanName()
 	if name == "" {
 		return
 	}
+	p.ImportPath = name
 
-	// Stop resolving imports if we've reached max depth or found a duplicate.
-	var importMode build.ImportMode
-	if p.Tree.hasSeenImport(name) || p.Tree.isAtMaxDepth(p) {
-		importMode = build.FindOnly
+	// Ignored packages are kept as leaves: we record the name but never ask
+	// the Importer to expand their dependencies.
+	if p.Tree.Ignored.Match(name) {
+		p.Ignored = true
+		return
 	}
 
-	pkg, err := i.Import(name, p.SrcDir, importMode)
+	// Probe in FindOnly mode first to learn the resolved PackagePath without
+	// paying for a full import. This lets us dedupe on the canonical package
+	// path rather than on name, so a dependency reached through more than
+	// one import path (eg. "x" and the vendored "vendor/x") is only ever
+	// expanded once.
+	probe, err := p.Tree.probeImport(i, name, p.SrcDir)
 	if err != nil {
 		// TODO: Check the error type?
 		p.Resolved = false
 		return
 	}
+	p.PackagePath = probe.ImportPath
+
+	// Stop resolving imports if we've reached max depth or found a
+	// duplicate of this resolved package elsewhere in the tree. The probe
+	// above already tells us everything a FindOnly import would, so reuse
+	// it instead of asking the Importer again.
+	seenKey := p.PackagePath
+	if seenKey == "" {
+		seenKey = name
+	}
+
+	pkg := probe
+	if !p.Tree.hasSeenImport(seenKey) && !p.Tree.isAtMaxDepth(p) {
+		pkg, err = p.Tree.fullImport(i, name, p.SrcDir)
+		if err != nil {
+			// TODO: Check the error type?
+			p.Resolved = false
+			return
+		}
+	}
 	p.Raw = pkg
+	p.PackagePath = pkg.ImportPath
 
 	// Update the name with the fully qualified import path.
 	p.Name = pkg.ImportPath
 
 	// If this is an internal dependency, we may need to skip it.
-	if pkg.Goroot {
-		p.Internal = true
-		if !p.Tree.shouldResolveInternal(p) {
-			return
-		}
+	p.Internal = pkg.Goroot
+	p.Relevance = p.Tree.relevanceOf(p)
+	if p.Internal && !p.Tree.shouldResolveInternal(p) {
+		return
 	}
 
-	//first we set the regular dependencies, then we add the test dependencies
-	//sharing the same set. This allows us to mark all test-only deps linearly
+	//first we set the regular dependencies, then the internal test deps, then
+	//the external (black-box) test deps, all sharing the same set. This
+	//allows us to mark all test-only deps linearly while still telling the
+	//two test flavors apart.
 	var unique stringSet
-	p.setDeps(i, pkg.Imports, pkg.Dir, &unique, false)
+	p.setDeps(i, pkg.Imports, pkg.Dir, &unique, TestNone)
 	if p.Tree.ResolveTest {
-		p.setDeps(i, append(pkg.TestImports, pkg.XTestImports...), pkg.Dir, &unique, true)
+		p.setDeps(i, pkg.TestImports, pkg.Dir, &unique, TestInternal)
+		p.setDeps(i, pkg.XTestImports, pkg.Dir, &unique, TestExternal)
 	}
 }
 
 // setDeps takes a slice of import paths and the source directory they are relative to,
 // and creates the Deps of the Pkg. Each dependency is also further resolved prior to being added
 // to the Pkg.
-func (p *Pkg) setDeps(i Importer, imports []string, srcDir string, unique *stringSet, isTest bool) {
+func (p *Pkg) setDeps(i Importer, imports []string, srcDir string, unique *stringSet, testType TestType) {
 	var wg sync.WaitGroup
 	for _, imp := range imports {
 		// Mostly for testing files where cyclic imports are allowed.
@@ -121,35 +230,99 @@ func (p *Pkg) setDeps(i Importer, imports []string, srcDir string, unique *strin
 		}
 		wg.Add(1)
 		go func(imp string) {
-			// TODO: limit number of goroutines (NOTE: this func is recursively called)
+			// Fan-out is recursive but bounded: Tree.probeImport and
+			// Tree.fullImport acquire a slot from the shared semaphore for
+			// the actual import and release it before this goroutine
+			// recurses into the result.
 			defer wg.Done()
-			p.addDep(i, imp, srcDir, isTest)
+			p.addDep(i, imp, srcDir, testType)
 		}(imp)
 	}
 	wg.Wait()
 
-	sort.Sort(byInternalAndName(p.Deps))
+	if p.Tree.SortBy == SortByInternal {
+		sort.Sort(byInternalAndName(p.Deps))
+	} else {
+		sort.Sort(byRelevanceAndName(p.Deps))
+	}
 }
 
-func (p *Pkg) appendDep(dep Pkg) {
+func (p *Pkg) appendDep(name string, dep Pkg) {
 	p.mu.Lock()
 	p.Deps = append(p.Deps, dep)
+	if p.Imports == nil {
+		p.Imports = make(map[string]string)
+	}
+	p.Imports[name] = dep.PackagePath
 	p.mu.Unlock()
 }
 
 // addDep creates a Pkg and it's dependencies from an imported package name.
-func (p *Pkg) addDep(i Importer, name, srcDir string, isTest bool) {
+func (p *Pkg) addDep(i Importer, name, srcDir string, testType TestType) {
 	dep := Pkg{
-		Name:   name,
-		SrcDir: srcDir,
-		Tree:   p.Tree,
-		Parent: p,
-		Test:   isTest,
+		Name:     name,
+		SrcDir:   srcDir,
+		Tree:     p.Tree,
+		Parent:   p,
+		TestType: testType,
 	}
 	dep.Resolve(i)
-	p.appendDep(dep)
+	p.appendDep(name, dep)
+}
+
+// pkgJSON mirrors Pkg for JSON rendering, splitting Deps by TestType into
+// separate "deps", "testDeps" and "xtestDeps" groups. Deps are referenced by
+// pointer into the original Pkg.Deps slice rather than copied, so marshaling
+// never copies a Pkg (and its embedded sync.Mutex) by value.
+type pkgJSON struct {
+	Name        string `json:"name"`
+	ImportPath  string `json:"importPath"`
+	PackagePath string `json:"packagePath"`
+	Internal    bool   `json:"internal"`
+	Resolved    bool   `json:"resolved"`
+	Ignored     bool   `json:"ignored"`
+	Relevance   int    `json:"relevance"`
+	Deps        []*Pkg `json:"deps"`
+	TestDeps    []*Pkg `json:"testDeps,omitempty"`
+	XTestDeps   []*Pkg `json:"xtestDeps,omitempty"`
+}
 
-	// p.Deps = append(p.Deps, dep)
+// MarshalJSON implements json.Marshaler, grouping Deps by TestType so
+// consumers can tell a regular dependency from an internal test dependency
+// or an external (black-box) test dependency.
+func (p *Pkg) MarshalJSON() ([]byte, error) {
+	out := pkgJSON{
+		Name:        p.Name,
+		ImportPath:  p.ImportPath,
+		PackagePath: p.PackagePath,
+		Internal:    p.Internal,
+		Resolved:    p.Resolved,
+		Ignored:     p.Ignored,
+		Relevance:   int(p.Relevance),
+	}
+	for i := range p.Deps {
+		dep := &p.Deps[i]
+		switch dep.TestType {
+		case TestInternal:
+			out.TestDeps = append(out.TestDeps, dep)
+		case TestExternal:
+			out.XTestDeps = append(out.XTestDeps, dep)
+		default:
+			out.Deps = append(out.Deps, dep)
+		}
+	}
+	return json.Marshal(out)
+}
+
+// DirectDep returns p's direct dependency whose resolved PackagePath matches
+// packagePath, or nil if p does not directly depend on it.
+func (p *Pkg) DirectDep(packagePath string) *Pkg {
+	for i := range p.Deps {
+		if p.Deps[i].PackagePath == packagePath {
+			return &p.Deps[i]
+		}
+	}
+	return nil
 }
 
 // depth returns the depth of the Pkg within the Tree.
@@ -212,3 +385,23 @@ func (b byInternalAndName) Less(i, j int) bool {
 
 	return b[i].Name < b[j].Name
 }
+
+// byRelevanceAndName sorts a slice of Pkgs by Relevance (stdlib first, then
+// same-module, direct and indirect dependencies, in that order), and
+// alphabetically by the last path component within a Relevance group.
+type byRelevanceAndName []Pkg
+
+func (b byRelevanceAndName) Len() int {
+	return len(b)
+}
+
+func (b byRelevanceAndName) Swap(i, j int) {
+	b[i], b[j] = b[j], b[i]
+}
+
+func (b byRelevanceAndName) Less(i, j int) bool {
+	if b[i].Relevance != b[j].Relevance {
+		return b[i].Relevance < b[j].Relevance
+	}
+	return path.Base(b[i].Name) < path.Base(b[j].Name)
+}
@@ -0,0 +1,58 @@
+package depth
+
+import (
+	"go/build"
+	"testing"
+)
+
+// vendorImporter simulates a package reachable under two different import
+// paths - a canonical one and a vendored alias - that both resolve to the
+// same PackagePath, plus a shared leaf reachable from both.
+type vendorImporter struct{}
+
+func (vendorImporter) Import(path, srcDir string, mode build.ImportMode) (*build.Package, error) {
+	var canonical string
+	switch path {
+	case "example.com/foo", "vendor/example.com/foo":
+		canonical = "vendor/example.com/foo"
+	default:
+		canonical = path
+	}
+
+	bp := &build.Package{ImportPath: canonical, Dir: canonical}
+	if mode&build.FindOnly == 0 && canonical == "vendor/example.com/foo" {
+		bp.Imports = []string{"shared"}
+	}
+	return bp, nil
+}
+
+// TestPkgResolveDedupesByPackagePath verifies that a dependency reached
+// through two different import paths which resolve to the same
+// PackagePath (the classic "x" vs. the vendored "vendor/x") is only ever
+// expanded once: exactly one of the two Pkg nodes gets its own Deps
+// resolved, the other is left as an unexpanded duplicate leaf.
+func TestPkgResolveDedupesByPackagePath(t *testing.T) {
+	root := &Pkg{Name: "root", Tree: &Tree{}}
+	root.Tree.Root = root
+	var unique stringSet
+	root.setDeps(vendorImporter{}, []string{"example.com/foo", "vendor/example.com/foo"}, "", &unique, TestNone)
+
+	if len(root.Deps) != 2 {
+		t.Fatalf("len(root.Deps) = %d, want 2", len(root.Deps))
+	}
+	for i := range root.Deps {
+		if root.Deps[i].PackagePath != "vendor/example.com/foo" {
+			t.Fatalf("dep.PackagePath = %q, want %q", root.Deps[i].PackagePath, "vendor/example.com/foo")
+		}
+	}
+
+	expanded := 0
+	for i := range root.Deps {
+		if len(root.Deps[i].Deps) > 0 {
+			expanded++
+		}
+	}
+	if expanded != 1 {
+		t.Errorf("expanded duplicate count = %d, want exactly 1", expanded)
+	}
+}
@@ -0,0 +1,78 @@
+package depth
+
+import (
+	"encoding/json"
+	"go/build"
+	"testing"
+)
+
+// graphImporter resolves import paths from a fixed graph, including test
+// and external test imports, for exercising Resolve end-to-end.
+type graphImporter struct {
+	imports      map[string][]string
+	testImports  map[string][]string
+	xtestImports map[string][]string
+}
+
+func (g *graphImporter) Import(path, srcDir string, mode build.ImportMode) (*build.Package, error) {
+	return &build.Package{
+		ImportPath:   path,
+		Dir:          path,
+		Imports:      g.imports[path],
+		TestImports:  g.testImports[path],
+		XTestImports: g.xtestImports[path],
+	}, nil
+}
+
+// TestPkgMarshalJSONSplitsTestDeps verifies that regular, internal-test and
+// external-test dependencies are rendered into separate JSON groups.
+func TestPkgMarshalJSONSplitsTestDeps(t *testing.T) {
+	imp := &graphImporter{
+		imports: map[string][]string{
+			"root": {"regular"},
+		},
+		testImports: map[string][]string{
+			"root": {"internal"},
+		},
+		xtestImports: map[string][]string{
+			"root": {"external"},
+		},
+	}
+
+	tree := Tree{ResolveTest: true}
+	if err := tree.Resolve("root", imp); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	data, err := json.Marshal(tree.Root)
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+
+	var out struct {
+		Deps      []struct{ Name string } `json:"deps"`
+		TestDeps  []struct{ Name string } `json:"testDeps"`
+		XTestDeps []struct{ Name string } `json:"xtestDeps"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if got := names(out.Deps); len(got) != 1 || got[0] != "regular" {
+		t.Errorf("deps = %v, want [regular]", got)
+	}
+	if got := names(out.TestDeps); len(got) != 1 || got[0] != "internal" {
+		t.Errorf("testDeps = %v, want [internal]", got)
+	}
+	if got := names(out.XTestDeps); len(got) != 1 || got[0] != "external" {
+		t.Errorf("xtestDeps = %v, want [external]", got)
+	}
+}
+
+func names(deps []struct{ Name string }) []string {
+	out := make([]string, len(deps))
+	for i, d := range deps {
+		out[i] = d.Name
+	}
+	return out
+}
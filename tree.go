@@ -0,0 +1,250 @@
+package depth
+
+import (
+	"go/build"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// defaultMaxConcurrency bounds Tree.MaxConcurrency when neither it nor
+// GOMAXPROCS yields a usable value.
+const defaultMaxConcurrency = 32
+
+// Importer resolves the *build.Package for an import path. Its signature
+// matches go/build.Importer so that *build.Context (eg. build.Default) can
+// be used directly, alongside alternative implementations such as
+// PackagesImporter.
+type Importer interface {
+	Import(path, srcDir string, mode build.ImportMode) (*build.Package, error)
+}
+
+// Tree represents the full dependency tree for a root package.
+type Tree struct {
+	Root *Pkg
+
+	// ResolveInternal, when true, causes packages in GOROOT to have their
+	// own dependencies resolved rather than being treated as leaves.
+	ResolveInternal bool
+
+	// ResolveTest, when true, causes test (and external test) imports to be
+	// resolved in addition to regular imports.
+	ResolveTest bool
+
+	// MaxDepth limits how many levels of the tree are resolved below the
+	// root; zero means no limit.
+	MaxDepth int
+
+	// Ignored lists packages and path/... prefix globs to exclude from
+	// expansion. Matching packages appear as leaves with Pkg.Ignored set.
+	Ignored *IgnoredRuleset
+
+	// MaxConcurrency bounds the number of filesystem/import operations
+	// in flight across the whole tree at once. Zero selects GOMAXPROCS,
+	// falling back to defaultMaxConcurrency if that is unusable.
+	MaxConcurrency int
+
+	// ModuleDir, if set, is the directory containing the root package's
+	// go.mod. It is read once to populate Pkg.Relevance.
+	ModuleDir string
+
+	// SortBy controls how each Pkg's Deps are ordered. The zero value,
+	// SortByRelevance, is the default.
+	SortBy SortOrder
+
+	seen stringSet
+
+	semOnce sync.Once
+	sem     chan struct{}
+
+	probeMu    sync.Mutex
+	probeCache map[probeCacheKey]*pkgCacheEntry
+
+	fullMu    sync.Mutex
+	fullCache map[string]*pkgCacheEntry
+
+	moduleOnce sync.Once
+	module     *moduleInfo
+}
+
+// SortOrder selects how a Tree orders each Pkg's Deps.
+type SortOrder int
+
+const (
+	// SortByRelevance orders deps by Pkg.Relevance, then alphabetically.
+	// This is the default.
+	SortByRelevance SortOrder = iota
+	// SortByInternal is the legacy ordering: stdlib above everything else,
+	// then alphabetically by import path.
+	SortByInternal
+)
+
+// probeCacheKey identifies a build.FindOnly probe by the same (name, srcDir)
+// pair passed to Importer.Import. Unlike a full import, which package a
+// given name probe resolves to, can legitimately depend on the importing
+// directory (vendoring, per-directory build tags, replace directives), so
+// probes are cached per srcDir rather than collapsed by result.
+type probeCacheKey struct {
+	name   string
+	srcDir string
+}
+
+// pkgCacheEntry memoizes the result of a single Importer.Import call.
+type pkgCacheEntry struct {
+	once sync.Once
+	pkg  *build.Package
+	err  error
+}
+
+// acquire blocks until a concurrency slot is available, lazily sizing the
+// semaphore from MaxConcurrency on first use.
+func (t *Tree) acquire() {
+	t.semOnce.Do(func() {
+		n := t.MaxConcurrency
+		if n <= 0 {
+			n = runtime.GOMAXPROCS(0)
+		}
+		if n <= 0 {
+			n = defaultMaxConcurrency
+		}
+		t.sem = make(chan struct{}, n)
+	})
+	t.sem <- struct{}{}
+}
+
+// release frees a concurrency slot acquired via acquire.
+func (t *Tree) release() {
+	<-t.sem
+}
+
+// probeImport resolves (name, srcDir) in build.FindOnly mode, reusing any
+// previous result for the same pair. The concurrency semaphore is held only
+// for the duration of the underlying Importer.Import call, never across a
+// caller's subsequent recursion — holding it any longer would let every
+// in-flight slot block in wg.Wait on children that can never acquire a slot
+// of their own.
+func (t *Tree) probeImport(importer Importer, name, srcDir string) (*build.Package, error) {
+	key := probeCacheKey{name: name, srcDir: srcDir}
+
+	t.probeMu.Lock()
+	if t.probeCache == nil {
+		t.probeCache = make(map[probeCacheKey]*pkgCacheEntry)
+	}
+	entry, ok := t.probeCache[key]
+	if !ok {
+		entry = &pkgCacheEntry{}
+		t.probeCache[key] = entry
+	}
+	t.probeMu.Unlock()
+
+	entry.once.Do(func() {
+		t.acquire()
+		defer t.release()
+		entry.pkg, entry.err = importer.Import(name, srcDir, build.FindOnly)
+	})
+	return entry.pkg, entry.err
+}
+
+// fullImport resolves (name, srcDir) with a full import, caching the result
+// by the resolved PackagePath rather than by (name, srcDir). This is what
+// makes a dependency reached from many different directories (eg. "fmt")
+// get passed to Importer.Import exactly once per Tree, rather than once per
+// distinct srcDir it happens to be imported from.
+func (t *Tree) fullImport(importer Importer, name, srcDir string) (*build.Package, error) {
+	probe, err := t.probeImport(importer, name, srcDir)
+	if err != nil {
+		return nil, err
+	}
+	key := probe.ImportPath
+	if key == "" {
+		key = name
+	}
+
+	t.fullMu.Lock()
+	if t.fullCache == nil {
+		t.fullCache = make(map[string]*pkgCacheEntry)
+	}
+	entry, ok := t.fullCache[key]
+	if !ok {
+		entry = &pkgCacheEntry{}
+		t.fullCache[key] = entry
+	}
+	t.fullMu.Unlock()
+
+	entry.once.Do(func() {
+		t.acquire()
+		defer t.release()
+		entry.pkg, entry.err = importer.Import(name, srcDir, 0)
+	})
+	return entry.pkg, entry.err
+}
+
+// Resolve builds the dependency Tree for the package at importPath, using
+// importer to resolve each package encountered. If importer is nil, the
+// default go/build-based resolution is used.
+func (t *Tree) Resolve(importPath string, importer Importer) error {
+	if importer == nil {
+		importer = &build.Default
+	}
+	if t.ModuleDir == "" {
+		if wd, err := os.Getwd(); err == nil {
+			t.ModuleDir = findModuleDir(wd)
+		}
+	}
+
+	t.seen.Reset()
+	root := &Pkg{
+		Name: importPath,
+		Tree: t,
+	}
+	root.Resolve(importer)
+	t.Root = root
+	return nil
+}
+
+// hasSeenImport reports whether name has already been resolved elsewhere in
+// the tree, recording it as seen if not.
+func (t *Tree) hasSeenImport(name string) bool {
+	return !t.seen.Add(name)
+}
+
+// isAtMaxDepth reports whether p sits at or beyond the tree's MaxDepth.
+func (t *Tree) isAtMaxDepth(p *Pkg) bool {
+	return t.MaxDepth > 0 && p.depth() >= t.MaxDepth
+}
+
+// shouldResolveInternal reports whether p, a GOROOT package, should have its
+// own dependencies expanded.
+func (t *Tree) shouldResolveInternal(p *Pkg) bool {
+	return t.ResolveInternal
+}
+
+// relevanceOf ranks p by how closely related it is to the root's module.
+func (t *Tree) relevanceOf(p *Pkg) Relevance {
+	if p.Internal {
+		return RelevanceStdlib
+	}
+
+	mod := t.loadedModule()
+	if mod == nil {
+		return RelevanceUnknown
+	}
+	switch {
+	case mod.sameModule(p.PackagePath):
+		return RelevanceSameModule
+	case mod.matches(mod.direct, p.PackagePath):
+		return RelevanceDirect
+	case mod.matches(mod.indirect, p.PackagePath):
+		return RelevanceIndirect
+	default:
+		return RelevanceUnknown
+	}
+}
+
+// loadedModule lazily loads the root's go.mod, at most once per Tree.
+func (t *Tree) loadedModule() *moduleInfo {
+	t.moduleOnce.Do(func() {
+		t.module = loadModuleInfo(t.ModuleDir)
+	})
+	return t.module
+}
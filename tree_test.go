@@ -0,0 +1,144 @@
+package depth
+
+import (
+	"go/build"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingImporter resolves import paths from a fixed graph and records how
+// many times each one is passed to Import, broken out by mode so tests can
+// assert on probe vs. full-import counts separately.
+type countingImporter struct {
+	graph map[string][]string
+
+	mu         sync.Mutex
+	fullCalls  map[string]int
+	probeCalls map[string]int
+}
+
+func (c *countingImporter) Import(path, srcDir string, mode build.ImportMode) (*build.Package, error) {
+	c.mu.Lock()
+	if mode&build.FindOnly != 0 {
+		if c.probeCalls == nil {
+			c.probeCalls = make(map[string]int)
+		}
+		c.probeCalls[path]++
+	} else {
+		if c.fullCalls == nil {
+			c.fullCalls = make(map[string]int)
+		}
+		c.fullCalls[path]++
+	}
+	c.mu.Unlock()
+
+	return &build.Package{
+		ImportPath: path,
+		Dir:        path,
+		Imports:    c.graph[path],
+	}, nil
+}
+
+// TestTreeFullImportCachedByPackagePath verifies that a dependency reached
+// from many different srcDirs (eg. "fmt") is still only passed to
+// Importer.Import once per Tree in non-FindOnly mode, per the shared
+// full-import cache keyed on the resolved PackagePath.
+func TestTreeFullImportCachedByPackagePath(t *testing.T) {
+	imp := &countingImporter{
+		graph: map[string][]string{
+			"root": {"a", "b", "c"},
+			"a":    {"shared"},
+			"b":    {"shared"},
+			"c":    {"shared"},
+		},
+	}
+
+	var tree Tree
+	if err := tree.Resolve("root", imp); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	if got := imp.fullCalls["shared"]; got != 1 {
+		t.Errorf("full Import calls for %q = %d, want 1 (srcDirs: a, b, c)", "shared", got)
+	}
+}
+
+// TestTreeMaxConcurrency verifies that MaxConcurrency bounds the number of
+// Importer.Import calls in flight at once, and that resolution still
+// completes (ie. no deadlock from holding a slot across recursion).
+func TestTreeMaxConcurrency(t *testing.T) {
+	const maxConcurrency = 2
+
+	blocking := &blockingImporter{
+		graph: map[string][]string{
+			"root": {"a", "b", "c", "d"},
+		},
+		release: make(chan struct{}),
+	}
+
+	tree := Tree{MaxConcurrency: maxConcurrency}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- tree.Resolve("root", blocking)
+	}()
+
+	blocking.waitForInFlight(t, maxConcurrency)
+	close(blocking.release)
+
+	if err := <-done; err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if got := len(tree.Root.Deps); got != 4 {
+		t.Errorf("len(Deps) = %d, want 4", got)
+	}
+	if blocking.peak > maxConcurrency {
+		t.Errorf("peak concurrent Import calls = %d, want <= %d", blocking.peak, maxConcurrency)
+	}
+}
+
+// blockingImporter blocks every leaf Import call until release is closed,
+// letting a test observe how many calls are in flight at once.
+type blockingImporter struct {
+	graph   map[string][]string
+	release chan struct{}
+
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (b *blockingImporter) Import(path, srcDir string, mode build.ImportMode) (*build.Package, error) {
+	imports, isRoot := b.graph[path]
+	if !isRoot {
+		b.mu.Lock()
+		b.inFlight++
+		if b.inFlight > b.peak {
+			b.peak = b.inFlight
+		}
+		b.mu.Unlock()
+
+		<-b.release
+
+		b.mu.Lock()
+		b.inFlight--
+		b.mu.Unlock()
+	}
+	return &build.Package{ImportPath: path, Dir: path, Imports: imports}, nil
+}
+
+func (b *blockingImporter) waitForInFlight(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		b.mu.Lock()
+		peak := b.peak
+		b.mu.Unlock()
+		if peak >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("never saw %d Import calls in flight", n)
+}